@@ -0,0 +1,140 @@
+// Package capture implements a pcap-style capture and replay format for Minecraft Bedrock packets. A Journal
+// transparently tees every packet marshaled through a protocol.Writer to disk via Recorder/NewWriter, and a
+// Replayer reads a Journal back and re-invokes Unmarshal on the packets it recorded, so that a session can be
+// reproduced offline against a fake client or server.
+//
+// Only the marshal (write) side is hooked up so far: there is no NewReader to transparently capture packets
+// as they are unmarshaled, and no CLI for inspecting a Journal outside of Go code. Both are left for a
+// follow-up change.
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// journalMagic identifies a capture file and journalVersion is the format version of the records that
+// follow the header. Both are written once, at the very start of a Journal.
+const (
+	journalMagic   uint32 = 0x47544341 // "GTCA"
+	journalVersion uint32 = 1
+)
+
+// Header is written once at the start of a Journal and describes the capture that follows. OpenJournal and
+// NewReplayer return it to the caller; neither compares it against the current protocol version or pool.
+type Header struct {
+	// ProtocolVersion is the Minecraft protocol version that was active when the capture was made.
+	ProtocolVersion int32
+	// Pool is a snapshot of the packet IDs the packet pool knew about at the time of capture. It is not
+	// required for replay: a Replayer only reports an unknown packet ID when Next reaches a Record with it.
+	Pool []uint32
+}
+
+// Journal is a versioned, self-describing capture file. It consists of the Header followed by
+// length-prefixed Records. Because every Record is individually length-prefixed and WriteRecord writes
+// straight through to the underlying io.Writer with no internal buffering, a Journal that is only partially
+// written, for example because the process capturing it crashed, can still be read back up to the last
+// complete Record.
+type Journal struct {
+	w io.Writer
+}
+
+// NewJournal creates a new Journal that writes to w, starting with the magic number, format version and the
+// header passed.
+func NewJournal(w io.Writer, header Header) (*Journal, error) {
+	if err := binary.Write(w, binary.BigEndian, journalMagic); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.BigEndian, journalVersion); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.BigEndian, header.ProtocolVersion); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(header.Pool))); err != nil {
+		return nil, err
+	}
+	for _, id := range header.Pool {
+		if err := binary.Write(w, binary.BigEndian, id); err != nil {
+			return nil, err
+		}
+	}
+	return &Journal{w: w}, nil
+}
+
+// WriteRecord appends rec to the Journal as a single length-prefixed record. It writes straight through to
+// the underlying io.Writer, with no internal buffering, so the record is visible to anything reading the
+// same data (a file, a pipe, a bytes.Buffer under test) as soon as WriteRecord returns.
+func (j *Journal) WriteRecord(rec Record) error {
+	b := rec.encode()
+	if err := binary.Write(j.w, binary.BigEndian, uint32(len(b))); err != nil {
+		return err
+	}
+	_, err := j.w.Write(b)
+	return err
+}
+
+// JournalReader reads back the Records written to a Journal by NewJournal/WriteRecord.
+type JournalReader struct {
+	r io.Reader
+}
+
+// OpenJournal reads the Header from r and returns a JournalReader that can be used to read the Records that
+// follow it. It returns an error if r does not start with a recognised magic number or format version.
+func OpenJournal(r io.Reader) (Header, *JournalReader, error) {
+	br := bufio.NewReader(r)
+
+	var magic, version uint32
+	if err := binary.Read(br, binary.BigEndian, &magic); err != nil {
+		return Header{}, nil, err
+	}
+	if magic != journalMagic {
+		return Header{}, nil, errors.New("capture: not a Journal (bad magic number)")
+	}
+	if err := binary.Read(br, binary.BigEndian, &version); err != nil {
+		return Header{}, nil, err
+	}
+	if version != journalVersion {
+		return Header{}, nil, errors.New("capture: unsupported Journal format version")
+	}
+
+	var header Header
+	if err := binary.Read(br, binary.BigEndian, &header.ProtocolVersion); err != nil {
+		return Header{}, nil, err
+	}
+	var poolLen uint32
+	if err := binary.Read(br, binary.BigEndian, &poolLen); err != nil {
+		return Header{}, nil, err
+	}
+	header.Pool = make([]uint32, poolLen)
+	for i := range header.Pool {
+		if err := binary.Read(br, binary.BigEndian, &header.Pool[i]); err != nil {
+			return Header{}, nil, err
+		}
+	}
+	return header, &JournalReader{r: br}, nil
+}
+
+// ReadRecord reads and decodes the next Record from the Journal. It returns io.EOF once the Journal has
+// been fully read. If the final record was only partially written, for example because the capturing
+// process crashed mid-write, ReadRecord returns io.ErrUnexpectedEOF so the caller can stop replay cleanly
+// instead of treating the whole capture as corrupt.
+func (jr *JournalReader) ReadRecord() (Record, error) {
+	var length uint32
+	if err := binary.Read(jr.r, binary.BigEndian, &length); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Record{}, io.ErrUnexpectedEOF
+		}
+		return Record{}, err
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(jr.r, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Record{}, err
+	}
+	return decodeRecord(b)
+}
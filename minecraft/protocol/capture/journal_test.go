@@ -0,0 +1,115 @@
+package capture_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/eren5960/gophertunnel408/minecraft/protocol"
+	"github.com/eren5960/gophertunnel408/minecraft/protocol/capture"
+	"github.com/eren5960/gophertunnel408/minecraft/protocol/packet"
+)
+
+// TestJournalRoundTrip checks that the Records written to a Journal come back out of OpenJournal/ReadRecord
+// unchanged, and that reading past the last Record reports a clean io.EOF.
+func TestJournalRoundTrip(t *testing.T) {
+	want := []capture.Record{
+		{PacketID: 1, Direction: protocol.DirectionClientToServer, EntityRuntimeID: 0, Timestamp: 100, Payload: []byte{1, 2, 3}},
+		{PacketID: 2, Direction: protocol.DirectionServerToClient, EntityRuntimeID: 42, Timestamp: 200, Payload: nil},
+		{PacketID: 3, Direction: protocol.DirectionClientToServer, EntityRuntimeID: 7, Timestamp: 300, Payload: []byte{}, Err: "boom"},
+	}
+
+	buf := new(bytes.Buffer)
+	journal, err := capture.NewJournal(buf, capture.Header{ProtocolVersion: 589, Pool: []uint32{1, 2, 3}})
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	for _, rec := range want {
+		if err := journal.WriteRecord(rec); err != nil {
+			t.Fatalf("WriteRecord: %v", err)
+		}
+	}
+
+	header, reader, err := capture.OpenJournal(buf)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	if header.ProtocolVersion != 589 || len(header.Pool) != 3 {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+
+	for i, exp := range want {
+		got, err := reader.ReadRecord()
+		if err != nil {
+			t.Fatalf("ReadRecord %d: %v", i, err)
+		}
+		if got.PacketID != exp.PacketID || got.Direction != exp.Direction || got.EntityRuntimeID != exp.EntityRuntimeID ||
+			got.Timestamp != exp.Timestamp || got.Err != exp.Err || !bytes.Equal(got.Payload, exp.Payload) {
+			t.Fatalf("record %d mismatch: got %+v, want %+v", i, got, exp)
+		}
+	}
+	if _, err := reader.ReadRecord(); err != io.EOF {
+		t.Fatalf("expected io.EOF after last record, got %v", err)
+	}
+}
+
+// TestWriteRecordVisibleImmediately checks that a single Record written to a Journal is visible to a reader
+// of the same underlying bytes right after WriteRecord returns, with no further writes or explicit flush in
+// between. This guards against WriteRecord buffering records internally instead of writing straight through:
+// an internally buffered Journal would still pass TestJournalRoundTrip once the buffer happened to fill or
+// the backing bytes.Buffer was read in full, but would fail this test for any single small record.
+func TestWriteRecordVisibleImmediately(t *testing.T) {
+	buf := new(bytes.Buffer)
+	journal, err := capture.NewJournal(buf, capture.Header{ProtocolVersion: 589})
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	if err := journal.WriteRecord(capture.Record{PacketID: 1, Timestamp: 100}); err != nil {
+		t.Fatalf("WriteRecord: %v", err)
+	}
+
+	_, reader, err := capture.OpenJournal(buf)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	if _, err := reader.ReadRecord(); err != nil {
+		t.Fatalf("ReadRecord: %v", err)
+	}
+}
+
+// TestRecorderCapturesMarshaledPacket checks that a Writer created with capture.NewWriter both forwards the
+// bytes it writes to the real destination and records them, under the right packet ID and direction, as a
+// Record in the Journal.
+func TestRecorderCapturesMarshaledPacket(t *testing.T) {
+	sink := new(bytes.Buffer)
+	journalBuf := new(bytes.Buffer)
+	journal, err := capture.NewJournal(journalBuf, capture.Header{ProtocolVersion: 589})
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+
+	w := capture.NewWriter(sink, journal, protocol.DirectionClientToServer)
+	pk := &packet.ContainerClose{WindowID: 7}
+	pk.Marshal(w)
+
+	_, reader, err := capture.OpenJournal(journalBuf)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	rec, err := reader.ReadRecord()
+	if err != nil {
+		t.Fatalf("ReadRecord: %v", err)
+	}
+	if rec.PacketID != pk.ID() {
+		t.Fatalf("got packet ID %v, want %v", rec.PacketID, pk.ID())
+	}
+	if rec.Direction != protocol.DirectionClientToServer {
+		t.Fatalf("got direction %v, want %v", rec.Direction, protocol.DirectionClientToServer)
+	}
+	if !bytes.Equal(rec.Payload, sink.Bytes()) {
+		t.Fatalf("recorded payload %v does not match bytes written to sink %v", rec.Payload, sink.Bytes())
+	}
+	if _, err := reader.ReadRecord(); err != io.EOF {
+		t.Fatalf("expected io.EOF after the only record, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/eren5960/gophertunnel408/minecraft/protocol"
+)
+
+// Record is a single packet captured by a Recorder: the raw, already-encoded bytes Marshal wrote for it
+// (including any NBT sub-blobs written through Writer.NBT), tagged with enough metadata to replay and
+// inspect it later.
+type Record struct {
+	// PacketID is the ID of the packet that was captured, as returned by its ID method.
+	PacketID uint32
+	// Direction is the direction the packet travelled in when it was captured.
+	Direction protocol.Direction
+	// EntityRuntimeID is the runtime ID of the entity the packet concerned, or 0 if it did not concern one.
+	EntityRuntimeID uint64
+	// Timestamp is the time the packet was captured, in Unix nanoseconds.
+	Timestamp int64
+	// Payload holds the raw bytes Marshal wrote for the packet.
+	Payload []byte
+	// Err is set to the error message recovered while marshaling the packet, if any. A non-empty Err means
+	// Payload may be incomplete.
+	Err string
+}
+
+// encode serialises rec into its on-disk representation.
+func (rec Record) encode() []byte {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, rec.PacketID)
+	writeString(buf, string(rec.Direction))
+	_ = binary.Write(buf, binary.BigEndian, rec.EntityRuntimeID)
+	_ = binary.Write(buf, binary.BigEndian, rec.Timestamp)
+	writeString(buf, rec.Err)
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(rec.Payload)))
+	buf.Write(rec.Payload)
+	return buf.Bytes()
+}
+
+// decodeRecord decodes a Record from the on-disk representation produced by Record.encode.
+func decodeRecord(b []byte) (Record, error) {
+	buf := bytes.NewReader(b)
+
+	var rec Record
+	if err := binary.Read(buf, binary.BigEndian, &rec.PacketID); err != nil {
+		return Record{}, fmt.Errorf("capture: read packet ID: %w", err)
+	}
+	direction, err := readString(buf)
+	if err != nil {
+		return Record{}, fmt.Errorf("capture: read direction: %w", err)
+	}
+	rec.Direction = protocol.Direction(direction)
+	if err := binary.Read(buf, binary.BigEndian, &rec.EntityRuntimeID); err != nil {
+		return Record{}, fmt.Errorf("capture: read entity runtime ID: %w", err)
+	}
+	if err := binary.Read(buf, binary.BigEndian, &rec.Timestamp); err != nil {
+		return Record{}, fmt.Errorf("capture: read timestamp: %w", err)
+	}
+	if rec.Err, err = readString(buf); err != nil {
+		return Record{}, fmt.Errorf("capture: read error message: %w", err)
+	}
+	var payloadLen uint32
+	if err := binary.Read(buf, binary.BigEndian, &payloadLen); err != nil {
+		return Record{}, fmt.Errorf("capture: read payload length: %w", err)
+	}
+	rec.Payload = make([]byte, payloadLen)
+	if _, err := io.ReadFull(buf, rec.Payload); err != nil {
+		return Record{}, fmt.Errorf("capture: read payload: %w", err)
+	}
+	return rec, nil
+}
+
+// writeString writes s to buf, prefixed with its length as a uint32.
+func writeString(buf *bytes.Buffer, s string) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+// readString reads a string from r that was written by writeString.
+func readString(r *bytes.Reader) (string, error) {
+	var l uint32
+	if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+		return "", err
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
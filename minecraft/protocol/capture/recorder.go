@@ -0,0 +1,85 @@
+package capture
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/eren5960/gophertunnel408/minecraft/protocol"
+)
+
+// Recorder implements protocol.Tracer by writing every packet it is asked to span, along with the raw bytes
+// written for it, to a Journal. It is installed through NewWriter so that capturing a session requires no
+// changes to the code that marshals packets on it. Packets that are unmarshaled are not captured.
+type Recorder struct {
+	journal *Journal
+	buf     *bytes.Buffer
+}
+
+// NewWriter wraps w in a *protocol.Writer that behaves exactly like one created with protocol.NewWriter,
+// except that every packet marshaled through it is additionally recorded to journal.
+func NewWriter(w interface {
+	io.Writer
+	io.ByteWriter
+}, journal *Journal, direction protocol.Direction) *protocol.Writer {
+	buf := new(bytes.Buffer)
+	tee := &teeWriter{w: w, buf: buf}
+	return protocol.NewWriterWithTracer(tee, &Recorder{journal: journal, buf: buf}, direction)
+}
+
+// StartSpan resets the Recorder's buffer so that it only holds the bytes written for the packet that is
+// about to be marshaled, and returns a Span that writes those bytes to the Journal once finished.
+func (rec *Recorder) StartSpan(packetID uint32, direction protocol.Direction) protocol.Span {
+	rec.buf.Reset()
+	return &recordSpan{rec: rec, packetID: packetID, direction: direction}
+}
+
+// recordSpan is the protocol.Span returned by Recorder.StartSpan.
+type recordSpan struct {
+	rec       *Recorder
+	packetID  uint32
+	direction protocol.Direction
+}
+
+// Finish writes the bytes buffered since the span was started to the Recorder's Journal as a Record.
+func (s *recordSpan) Finish(_ int, entityRuntimeID uint64, err error) {
+	payload := make([]byte, s.rec.buf.Len())
+	copy(payload, s.rec.buf.Bytes())
+
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	// An error writing the Record is discarded: Finish does not panic, so it cannot mask the original packet
+	// serialisation error, and the failed Record is simply missing from the Journal.
+	_ = s.rec.journal.WriteRecord(Record{
+		PacketID:        s.packetID,
+		Direction:       s.direction,
+		EntityRuntimeID: entityRuntimeID,
+		Timestamp:       time.Now().UnixNano(),
+		Payload:         payload,
+		Err:             errMsg,
+	})
+}
+
+// teeWriter forwards every write to the real destination w, while also appending it to buf so a
+// *recordSpan can pick the bytes for the packet it is tracing up once that packet finishes (de)serialising.
+type teeWriter struct {
+	w interface {
+		io.Writer
+		io.ByteWriter
+	}
+	buf *bytes.Buffer
+}
+
+// Write implements io.Writer.
+func (t *teeWriter) Write(p []byte) (int, error) {
+	t.buf.Write(p)
+	return t.w.Write(p)
+}
+
+// WriteByte implements io.ByteWriter.
+func (t *teeWriter) WriteByte(b byte) error {
+	t.buf.WriteByte(b)
+	return t.w.WriteByte(b)
+}
@@ -0,0 +1,54 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/eren5960/gophertunnel408/minecraft/protocol"
+	"github.com/eren5960/gophertunnel408/minecraft/protocol/packet"
+)
+
+// Replayer reads the Records captured in a Journal back and re-invokes Unmarshal on the packet each Record
+// held, using the pool passed to NewReplayer to construct an empty instance of the right packet type. This
+// lets a session bug recorded earlier be reproduced offline against a fake client or server.
+type Replayer struct {
+	journal *JournalReader
+	pool    packet.Pool
+}
+
+// NewReplayer opens the Journal written to r and returns a Replayer for it along with the Header the
+// Journal was captured with. Packets read back from the Journal are constructed using pool; a Record whose
+// packet ID is not in pool is reported as an error from Next rather than stopping the replay.
+func NewReplayer(r io.Reader, pool packet.Pool) (Header, *Replayer, error) {
+	header, jr, err := OpenJournal(r)
+	if err != nil {
+		return Header{}, nil, fmt.Errorf("capture: open journal: %w", err)
+	}
+	return header, &Replayer{journal: jr, pool: pool}, nil
+}
+
+// Next reads and unmarshals the next packet recorded in the Journal. It returns io.EOF once every Record has
+// been replayed. A Record whose trailing bytes were truncated, as can happen if the capture was interrupted
+// mid-write, ends replay the same way as reaching a clean end of the Journal.
+func (rep *Replayer) Next() (pk packet.Packet, direction protocol.Direction, entityRuntimeID uint64, err error) {
+	rec, err := rep.journal.ReadRecord()
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, "", 0, io.EOF
+		}
+		return nil, "", 0, err
+	}
+
+	factory, ok := rep.pool[rec.PacketID]
+	if !ok {
+		return nil, "", 0, fmt.Errorf("capture: replay packet with ID %v: not present in pool", rec.PacketID)
+	}
+	pk = factory()
+
+	if rec.Err != "" {
+		return pk, rec.Direction, rec.EntityRuntimeID, fmt.Errorf("capture: packet was captured with a marshal error: %v", rec.Err)
+	}
+	pk.Unmarshal(protocol.NewReader(bytes.NewReader(rec.Payload)))
+	return pk, rec.Direction, rec.EntityRuntimeID, nil
+}
@@ -24,8 +24,10 @@ func (*BlockActorData) ID() uint32 {
 
 // Marshal ...
 func (pk *BlockActorData) Marshal(w *protocol.Writer) {
-	w.UBlockPos(&pk.Position)
-	w.NBT(&pk.NBTData, nbt.NetworkLittleEndian)
+	w.TracePacket(pk.ID(), nil, func() {
+		w.UBlockPos(&pk.Position)
+		w.NBT(&pk.NBTData, nbt.NetworkLittleEndian)
+	})
 }
 
 // Unmarshal ...
@@ -20,7 +20,9 @@ func (*ContainerClose) ID() uint32 {
 
 // Marshal ...
 func (pk *ContainerClose) Marshal(w *protocol.Writer) {
-	w.Uint8(&pk.WindowID)
+	w.TracePacket(pk.ID(), nil, func() {
+		w.Uint8(&pk.WindowID)
+	})
 }
 
 // Unmarshal ...
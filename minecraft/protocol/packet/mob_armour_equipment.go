@@ -30,11 +30,13 @@ func (*MobArmourEquipment) ID() uint32 {
 
 // Marshal ...
 func (pk *MobArmourEquipment) Marshal(w *protocol.Writer) {
-	w.Varuint64(&pk.EntityRuntimeID)
-	w.Item(&pk.Helmet)
-	w.Item(&pk.Chestplate)
-	w.Item(&pk.Leggings)
-	w.Item(&pk.Boots)
+	w.TracePacket(pk.ID(), &pk.EntityRuntimeID, func() {
+		w.Varuint64(&pk.EntityRuntimeID)
+		w.Item(&pk.Helmet)
+		w.Item(&pk.Chestplate)
+		w.Item(&pk.Leggings)
+		w.Item(&pk.Boots)
+	})
 }
 
 // Unmarshal ...
@@ -23,8 +23,10 @@ func (*UpdateAttributes) ID() uint32 {
 
 // Marshal ...
 func (pk *UpdateAttributes) Marshal(w *protocol.Writer) {
-	w.Varuint64(&pk.EntityRuntimeID)
-	protocol.WriteAttributes(w, &pk.Attributes)
+	w.TracePacket(pk.ID(), &pk.EntityRuntimeID, func() {
+		w.Varuint64(&pk.EntityRuntimeID)
+		protocol.WriteAttributes(w, &pk.Attributes)
+	})
 }
 
 // Unmarshal ...
@@ -0,0 +1,65 @@
+package protocol
+
+import "fmt"
+
+// Direction identifies which way a packet travelled when it was traced, so that a Tracer can label spans
+// without needing to inspect the Writer/Reader it was attached to.
+type Direction string
+
+const (
+	// DirectionServerToClient is used for packets written by a server/read by a client.
+	DirectionServerToClient Direction = "server->client"
+	// DirectionClientToServer is used for packets written by a client/read by a server.
+	DirectionClientToServer Direction = "client->server"
+)
+
+// Tracer may be implemented by users of this package to record spans for every packet (de)serialised
+// through a Writer or Reader. It deliberately has no dependency on any specific tracing backend, so that
+// users can adapt it to Zipkin, Jaeger, OpenTelemetry or any other system of their choosing.
+//
+// Only Writer.TracePacket is implemented so far: Reader tracing (packet deserialisation spans) is not yet
+// wired up and is left for a follow-up change.
+type Tracer interface {
+	// StartSpan starts a new span for the packet with the ID and direction passed. The returned Span must
+	// be finished once the packet has finished (de)serialising.
+	StartSpan(packetID uint32, direction Direction) Span
+}
+
+// Span represents a single in-flight span produced by a Tracer for one packet (de)serialisation.
+type Span interface {
+	// Finish ends the span, recording the size in bytes of the packet that was (de)serialised, the entity
+	// runtime ID it carried (0 if it does not carry one) and, if a panic was recovered while
+	// (de)serialising, the error that caused it. err is nil on success.
+	Finish(size int, entityRuntimeID uint64, err error)
+}
+
+// TracePacket wraps f, which should (de)serialise a single packet, in a span obtained from the Writer's
+// Tracer. If no Tracer is set, f is called directly and no tracing overhead is incurred. entityRuntimeID may
+// be nil for packets that do not carry one; otherwise it is read once f returns, so that it reflects a value
+// decoded by f itself rather than one that may not yet be set when tracing a Reader. Panics raised by f are
+// recorded on the span and re-panicked so existing recover-based error handling keeps working.
+func (w *Writer) TracePacket(packetID uint32, entityRuntimeID *uint64, f func()) {
+	if w.tracer == nil {
+		f()
+		return
+	}
+	before := w.n
+	span := w.tracer.StartSpan(packetID, w.direction)
+	defer func() {
+		size := w.n - before
+		var id uint64
+		if entityRuntimeID != nil {
+			id = *entityRuntimeID
+		}
+		if r := recover(); r != nil {
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			span.Finish(size, id, err)
+			panic(r)
+		}
+		span.Finish(size, id, nil)
+	}()
+	f()
+}
@@ -8,20 +8,98 @@ import (
 	"image/color"
 	"io"
 	"reflect"
+	"sync"
 )
 
+// bufferPool pools the byte slices backing a pooled Writer, so that marshaling a packet through
+// NewPooledWriter does not need to allocate a fresh buffer for every packet.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return make([]byte, 0, 1024) },
+}
+
 // Writer implements writing methods for data types from Minecraft packets. Each Packet implementation has one
 // passed to it when writing.
 // Writer implements methods where values are passed using a pointer, so that Reader and Writer have a
 // synonymous interface and both implement the IO interface.
+//
+// A Writer created with NewPooledWriter holds its own growable buffer and never goes through an io.Writer
+// for the individual fields a Marshal implementation writes, which avoids the interface dispatch and
+// per-call error handling that writing straight to an io.Writer would otherwise incur. A Writer created with
+// NewWriter instead forwards every write straight to the io.Writer passed in, for source compatibility with
+// existing callers.
 type Writer struct {
-	w interface {
+	buf []byte
+	w   interface {
 		io.Writer
 		io.ByteWriter
 	}
+	n         int
+	tracer    Tracer
+	direction Direction
+	safe      bool
+	err       error
+}
+
+// Packet is implemented by types that can marshal themselves to a Writer, such as every packet in the
+// packet package. It is declared here, rather than imported from the packet package, to avoid a circular
+// dependency between the two: SafeMarshal needs a type to call Marshal on, but the packet package already
+// imports this one.
+type Packet interface {
+	Marshal(w *Writer)
+}
+
+// SafeMarshal marshals pk using a Writer created in safe mode and returns the bytes it wrote. Unlike calling
+// pk.Marshal on a normal Writer, SafeMarshal never panics: conditions that would otherwise panic, such as an
+// unknown enum value, an invalid field or a failed NBT encode, are instead recorded and returned as the
+// error, so that a caller can log and drop a malformed packet instead of recovering from a panic at every
+// packet boundary.
+//
+// A non-nil error means the returned bytes are not a valid packet: Marshal kept running after the error was
+// recorded, so the field that triggered it may be missing its type tag or value (for example, an
+// EntityMetadata entry with an unsupported value type is written as its key alone) while the fields after it
+// are still written, rather than the output just being truncated at the end. Callers must discard the bytes
+// entirely on error, not try to salvage a prefix of them.
+func SafeMarshal(pk Packet) ([]byte, error) {
+	w := NewPooledWriter()
+	w.safe = true
+	pk.Marshal(w)
+
+	// The buffer is about to be returned to the pool, so it must be copied out first: otherwise a later
+	// SafeMarshal call could reuse and overwrite it while the caller still holds the result.
+	b := make([]byte, len(w.Buf()))
+	copy(b, w.Buf())
+	err := w.Err()
+	w.Release()
+	return b, err
+}
+
+// Err returns the first error recorded by the Writer since it was created. It is only populated for a
+// Writer in safe mode, such as the one SafeMarshal uses; a Writer not in safe mode panics instead of
+// recording an error, so Err always returns nil for it.
+//
+// A non-nil Err means whatever was written after the error was recorded is structurally invalid, not just
+// truncated: Marshal keeps running rather than unwinding, so the field that caused the error may be missing a
+// type tag or value entirely while later fields are still written after it. Treat Err() != nil as "discard everything written", never as "keep the prefix".
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// recordErr panics with err, unless the Writer is in safe mode, in which case it records err as the
+// Writer's first error instead, if one was not already recorded.
+func (w *Writer) recordErr(err error) {
+	if !w.safe {
+		panic(err)
+	}
+	if w.err == nil {
+		w.err = err
+	}
 }
 
-// NewWriter creates a new initialised Writer with an underlying io.ByteWriter to write to.
+// NewWriter creates a new initialised Writer with an underlying io.ByteWriter to write to. Every write goes
+// straight through to w, exactly as before the pooled-buffer redesign, so Buf and FlushTo are no-ops on a
+// Writer returned by NewWriter; it is kept only for source compatibility with callers that already have a
+// destination to write to. New code that marshals a packet before it has one should prefer NewPooledWriter,
+// Buf and FlushTo.
 func NewWriter(w interface {
 	io.Writer
 	io.ByteWriter
@@ -29,42 +107,128 @@ func NewWriter(w interface {
 	return &Writer{w: w}
 }
 
+// NewWriterWithTracer creates a new initialised Writer with an underlying io.ByteWriter to write to, whose
+// packet writes are reported to the Tracer passed as spans tagged with the direction passed. Use
+// Writer.TracePacket in a Marshal implementation to produce a span for that packet.
+func NewWriterWithTracer(w interface {
+	io.Writer
+	io.ByteWriter
+}, tracer Tracer, direction Direction) *Writer {
+	return &Writer{w: w, tracer: tracer, direction: direction}
+}
+
+// NewPooledWriter creates a new initialised Writer backed by a growable buffer obtained from a sync.Pool,
+// rather than an io.Writer passed in up front. Marshal implementations that write through it allocate
+// nothing as long as the buffer does not need to grow. Call Buf once finished to read out what was written,
+// FlushTo to write it straight to a destination, and Release to return the buffer to the pool.
+func NewPooledWriter() *Writer {
+	return &Writer{buf: bufferPool.Get().([]byte)[:0]}
+}
+
+// Buf returns the bytes written so far to a Writer created with NewPooledWriter. It is named Buf, rather
+// than Bytes, because Writer already has a Bytes method that writes a []byte field to the underlying
+// buffer. It returns nil for a Writer created with NewWriter or NewWriterWithTracer, since those write
+// straight through to their underlying io.Writer instead of buffering.
+func (w *Writer) Buf() []byte {
+	return w.buf
+}
+
+// Reset clears the bytes written so far to a Writer created with NewPooledWriter, so that it may be reused
+// for the next packet without returning its buffer to the pool in between.
+func (w *Writer) Reset() {
+	if w.buf != nil {
+		w.buf = w.buf[:0]
+	}
+	w.n = 0
+}
+
+// Release returns the buffer backing a Writer created with NewPooledWriter to the pool it came from. The
+// Writer must not be used again afterwards. Release is a no-op for a Writer created with NewWriter or
+// NewWriterWithTracer.
+func (w *Writer) Release() {
+	if w.buf != nil {
+		//nolint:staticcheck // the slice is intentionally handed back for reuse by another Writer.
+		bufferPool.Put(w.buf)
+		w.buf = nil
+	}
+}
+
+// FlushTo writes the bytes written so far to a Writer created with NewPooledWriter to dst in a single
+// Write call, then clears them as Reset would. It is a no-op for a Writer created with NewWriter or
+// NewWriterWithTracer, which already wrote straight through to their destination.
+func (w *Writer) FlushTo(dst io.Writer) error {
+	if w.buf == nil {
+		return nil
+	}
+	_, err := dst.Write(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+// writeByte appends b to the underlying buffer, or writes it straight through to the underlying io.Writer
+// if the Writer was not created with NewPooledWriter.
+func (w *Writer) writeByte(b byte) {
+	w.n++
+	if w.buf != nil {
+		w.buf = append(w.buf, b)
+		return
+	}
+	_ = w.w.WriteByte(b)
+}
+
+// write appends p to the underlying buffer, or writes it straight through to the underlying io.Writer if the
+// Writer was not created with NewPooledWriter.
+func (w *Writer) write(p []byte) {
+	w.n += len(p)
+	if w.buf != nil {
+		w.buf = append(w.buf, p...)
+		return
+	}
+	_, _ = w.w.Write(p)
+}
+
+// Write implements io.Writer so that an nbt.Encoder can encode straight into a Writer's buffer.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.write(p)
+	return len(p), nil
+}
+
 // Uint8 writes a uint8 to the underlying buffer.
 func (w *Writer) Uint8(x *uint8) {
-	_ = w.w.WriteByte(*x)
+	w.writeByte(*x)
 }
 
 // Bool writes a bool as either 0 or 1 to the underlying buffer.
 func (w *Writer) Bool(x *bool) {
 	if *x {
-		_ = w.w.WriteByte(1)
+		w.writeByte(1)
 		return
 	}
-	_ = w.w.WriteByte(0)
+	w.writeByte(0)
 }
 
 // String writes a string, prefixed with a varuint32, to the underlying buffer.
 func (w *Writer) String(x *string) {
 	l := uint32(len(*x))
 	w.Varuint32(&l)
-	_, _ = w.w.Write([]byte(*x))
+	w.write([]byte(*x))
 }
 
 // ByteSlice writes a []byte, prefixed with a varuint32, to the underlying buffer.
 func (w *Writer) ByteSlice(x *[]byte) {
 	l := uint32(len(*x))
 	w.Varuint32(&l)
-	_, _ = w.w.Write(*x)
+	w.write(*x)
 }
 
 // Bytes appends a []byte to the underlying buffer.
 func (w *Writer) Bytes(x *[]byte) {
-	_, _ = w.w.Write(*x)
+	w.write(*x)
 }
 
 // ByteFloat writes a rotational float32 as a single byte to the underlying buffer.
 func (w *Writer) ByteFloat(x *float32) {
-	_ = w.w.WriteByte(byte(*x / (360.0 / 256.0)))
+	w.writeByte(byte(*x / (360.0 / 256.0)))
 }
 
 // Vec3 writes an mgl32.Vec3 as 3 float32s to the underlying buffer.
@@ -107,7 +271,7 @@ func (w *Writer) UUID(x *uuid.UUID) {
 	for i, j := 0, 15; i < j; i, j = i+1, j-1 {
 		b[i], b[j] = b[j], b[i]
 	}
-	_, _ = w.w.Write(b)
+	w.write(b)
 }
 
 // EntityMetadata writes an entity metadata map x to the underlying buffer.
@@ -197,21 +361,29 @@ func (w *Writer) Varint64(x *int64) {
 	if u < 0 {
 		ux = ^ux
 	}
+	var scratch [10]byte
+	n := 0
 	for ux >= 0x80 {
-		_ = w.w.WriteByte(byte(ux) | 0x80)
+		scratch[n] = byte(ux) | 0x80
 		ux >>= 7
+		n++
 	}
-	_ = w.w.WriteByte(byte(ux))
+	scratch[n] = byte(ux)
+	w.write(scratch[:n+1])
 }
 
 // Varuint64 writes a uint64 as 1-10 bytes to the underlying buffer.
 func (w *Writer) Varuint64(x *uint64) {
 	u := *x
+	var scratch [10]byte
+	n := 0
 	for u >= 0x80 {
-		_ = w.w.WriteByte(byte(u) | 0x80)
+		scratch[n] = byte(u) | 0x80
 		u >>= 7
+		n++
 	}
-	_ = w.w.WriteByte(byte(u))
+	scratch[n] = byte(u)
+	w.write(scratch[:n+1])
 }
 
 // Varint32 writes an int32 as 1-5 bytes to the underlying buffer.
@@ -221,48 +393,59 @@ func (w *Writer) Varint32(x *int32) {
 	if u < 0 {
 		ux = ^ux
 	}
+	var scratch [5]byte
+	n := 0
 	for ux >= 0x80 {
-		_ = w.w.WriteByte(byte(ux) | 0x80)
+		scratch[n] = byte(ux) | 0x80
 		ux >>= 7
+		n++
 	}
-	_ = w.w.WriteByte(byte(ux))
+	scratch[n] = byte(ux)
+	w.write(scratch[:n+1])
 }
 
 // Varuint32 writes a uint32 as 1-5 bytes to the underlying buffer.
 func (w *Writer) Varuint32(x *uint32) {
 	u := *x
+	var scratch [5]byte
+	n := 0
 	for u >= 0x80 {
-		_ = w.w.WriteByte(byte(u) | 0x80)
+		scratch[n] = byte(u) | 0x80
 		u >>= 7
+		n++
 	}
-	_ = w.w.WriteByte(byte(u))
+	scratch[n] = byte(u)
+	w.write(scratch[:n+1])
 }
 
 // NBT writes a map as NBT to the underlying buffer using the encoding passed.
 func (w *Writer) NBT(x *map[string]interface{}, encoding nbt.Encoding) {
-	if err := nbt.NewEncoderWithEncoding(w.w, encoding).Encode(*x); err != nil {
-		panic(err)
+	if err := nbt.NewEncoderWithEncoding(w, encoding).Encode(*x); err != nil {
+		w.recordErr(err)
 	}
 }
 
 // NBTList writes a slice as NBT to the underlying buffer using the encoding passed.
 func (w *Writer) NBTList(x *[]interface{}, encoding nbt.Encoding) {
-	if err := nbt.NewEncoderWithEncoding(w.w, encoding).Encode(*x); err != nil {
-		panic(err)
+	if err := nbt.NewEncoderWithEncoding(w, encoding).Encode(*x); err != nil {
+		w.recordErr(err)
 	}
 }
 
-// UnknownEnumOption panics with an unknown enum option error.
+// UnknownEnumOption panics with an unknown enum option error, or records it as the Writer's error if the
+// Writer is in safe mode.
 func (w *Writer) UnknownEnumOption(value interface{}, enum string) {
 	w.panicf("unknown value '%v' for enum type '%v'", value, enum)
 }
 
-// InvalidValue panics with an invalid value error.
+// InvalidValue panics with an invalid value error, or records it as the Writer's error if the Writer is in
+// safe mode.
 func (w *Writer) InvalidValue(value interface{}, forField, reason string) {
 	w.panicf("invalid value '%v' for %v: %v", value, forField, reason)
 }
 
-// panicf panics with the format and values passed.
+// panicf records the format and values passed as the Writer's error if the Writer is in safe mode, or
+// panics with them otherwise.
 func (w *Writer) panicf(format string, a ...interface{}) {
-	panic(fmt.Errorf(format, a...))
+	w.recordErr(fmt.Errorf(format, a...))
 }
@@ -0,0 +1,96 @@
+package protocol_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/eren5960/gophertunnel408/minecraft/nbt"
+	"github.com/eren5960/gophertunnel408/minecraft/protocol"
+)
+
+// writeInventoryItem writes one inventory slot shaped like what MobArmourEquipment.Marshal writes for a
+// single ItemStack field: a network ID, an aux value, a small NBT blob and two short string lists.
+func writeInventoryItem(w *protocol.Writer) {
+	networkID, aux := int32(351), int32(1)
+	w.Varint32(&networkID)
+	w.Varint32(&aux)
+	w.NBT(&map[string]interface{}{"Damage": int32(0), "Unbreakable": byte(0)}, nbt.NetworkLittleEndian)
+
+	placeOnLen, canBreakLen := int32(1), int32(1)
+	w.Varint32(&placeOnLen)
+	stone := "minecraft:stone"
+	w.String(&stone)
+	w.Varint32(&canBreakLen)
+	dirt := "minecraft:dirt"
+	w.String(&dirt)
+}
+
+// writeAttribute writes one entry shaped like what protocol.WriteAttributes writes per protocol.Attribute:
+// a name and four float32s (minimum, maximum, value and default).
+func writeAttribute(w *protocol.Writer) {
+	name := "minecraft:health"
+	w.String(&name)
+	min, max, value, def := float32(0), float32(20), float32(20), float32(20)
+	w.Float32(&min)
+	w.Float32(&max)
+	w.Float32(&value)
+	w.Float32(&def)
+}
+
+// BenchmarkWriterMobArmourEquipment approximates the cost of marshaling a MobArmourEquipment packet with a
+// full set of armour, each piece carrying a small NBT blob and block lists, as Item would write it.
+func BenchmarkWriterMobArmourEquipment(b *testing.B) {
+	b.ReportAllocs()
+	w := protocol.NewPooledWriter()
+	defer w.Release()
+
+	entityRuntimeID := uint64(1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Reset()
+		w.Varuint64(&entityRuntimeID)
+		for piece := 0; piece < 4; piece++ {
+			writeInventoryItem(w)
+		}
+	}
+}
+
+// BenchmarkWriterUpdateAttributes approximates the cost of marshaling an UpdateAttributes packet carrying a
+// representative number of attributes.
+func BenchmarkWriterUpdateAttributes(b *testing.B) {
+	const attributeCount = 8
+
+	b.ReportAllocs()
+	w := protocol.NewPooledWriter()
+	defer w.Release()
+
+	entityRuntimeID := uint64(1)
+	count := uint32(attributeCount)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Reset()
+		w.Varuint64(&entityRuntimeID)
+		w.Varuint32(&count)
+		for a := 0; a < attributeCount; a++ {
+			writeAttribute(w)
+		}
+	}
+}
+
+// BenchmarkWriterMobArmourEquipmentUnpooled benchmarks the same MobArmourEquipment-shaped write through a
+// Writer created with NewWriter, to show the allocation and dispatch cost the pooled-buffer redesign avoids.
+func BenchmarkWriterMobArmourEquipmentUnpooled(b *testing.B) {
+	b.ReportAllocs()
+	buf := new(bytes.Buffer)
+
+	entityRuntimeID := uint64(1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		w := protocol.NewWriter(buf)
+		w.Varuint64(&entityRuntimeID)
+		for piece := 0; piece < 4; piece++ {
+			writeInventoryItem(w)
+		}
+	}
+}